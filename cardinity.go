@@ -2,24 +2,58 @@ package cardinity
 
 import (
 	"bytes"
-	"crypto/md5"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/appinesshq/cardinity-sdk-go/oauth1"
 	"github.com/pkg/errors"
 )
 
 const baseURL = "https://api.cardinity.com/v1/"
 
+// Logger is the minimal logging interface Cardinity needs for debug
+// tracing. *log.Logger does not satisfy this directly; use NewLogger
+// to wrap one, or provide your own (e.g. backed by logrus/zap).
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// NewLogger wraps a standard library *log.Logger so it satisfies
+// Logger.
+func NewLogger(l *log.Logger) Logger {
+	return &stdLogger{l: l}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (s *stdLogger) Debugf(format string, args ...interface{}) {
+	s.l.Printf("[DEBUG] "+format, args...)
+}
+
+func (s *stdLogger) Errorf(format string, args ...interface{}) {
+	s.l.Printf("[ERROR] "+format, args...)
+}
+
 // Cardinity gives access to the Cardinity API.
 type Cardinity struct {
-	log log.Logger
+	log Logger
 
 	// ConsumerKey is the OAuth1 consumer key for the Cardinity service.
 	ConsumerKey string
@@ -29,6 +63,30 @@ type Cardinity struct {
 
 	// Debug enables debug mode when set to true.
 	Debug bool
+
+	// Retryer determines whether and how a failed request should be
+	// retried. It defaults to a *StandardRetryer.
+	Retryer Retryer
+
+	// HTTPClient is the client used to perform requests. If nil, a
+	// zero-value *http.Client is used for every call. Set this to
+	// control timeouts, transport, and middleware.
+	HTTPClient *http.Client
+
+	// BaseURL overrides the Cardinity API base URL. If empty, the
+	// production API is used. Tests point this at an httptest.Server.
+	// Only BaseURL's scheme and host are used; any path component is
+	// discarded, so a BaseURL like "https://sandbox.example.com/proxy-prefix/"
+	// will NOT route requests under "/proxy-prefix/" — it routes them
+	// to the same "/v1/..." paths the production API uses, just on
+	// that host instead.
+	BaseURL string
+
+	// Signer signs outgoing requests with OAuth1. If nil, a
+	// HMAC-SHA1 signer using ConsumerKey/ConsumerSecret is used, which
+	// matches Cardinity's default OAuth1 configuration. Set it to an
+	// oauth1.NewHMACSHA256Signer to use HMAC-SHA256 instead.
+	Signer oauth1.Signer
 }
 
 // New returns an initialized APi wrapper.
@@ -36,9 +94,79 @@ func New(consumerKey, consumerSecret string) *Cardinity {
 	return &Cardinity{
 		ConsumerKey:    consumerKey,
 		ConsumerSecret: consumerSecret,
+		Retryer:        NewStandardRetryer(),
+		log:            NewLogger(log.New(os.Stderr, "cardinity: ", log.LstdFlags)),
+	}
+}
+
+// apiBaseURL returns the effective API base URL, honoring BaseURL when
+// the caller has set one.
+func (c *Cardinity) apiBaseURL() string {
+	if c.BaseURL != "" {
+		return c.BaseURL
+	}
+	return baseURL
+}
+
+// resolveURL rewrites u's scheme and host to point at apiBaseURL(),
+// preserving its path and query untouched. Requests are always built
+// with a path already rooted at the production baseURL constant
+// (e.g. "/v1/payments/abc"), so only the scheme/host need swapping;
+// rewriting the path too would double up the "/v1" prefix for the
+// (default, no-op) production case, since apiBaseURL() itself
+// includes that prefix.
+func (c *Cardinity) resolveURL(u *url.URL) (*url.URL, error) {
+	base, err := url.Parse(c.apiBaseURL())
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing BaseURL")
+	}
+
+	resolved := *u
+	resolved.Scheme = base.Scheme
+	resolved.Host = base.Host
+	return &resolved, nil
+}
+
+// httpClient returns the effective HTTP client, falling back to a
+// zero-value client when the caller hasn't supplied one.
+func (c *Cardinity) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return &http.Client{}
+}
+
+// redactedHeaders lists the headers whose values are replaced with
+// "REDACTED" in debug dumps because they carry OAuth1 signing material
+// or bearer credentials.
+var redactedHeaders = []string{"Authorization: "}
+
+// redactDump blanks out sensitive header lines in an httputil dump so
+// traces are safe to paste into bug reports.
+func redactDump(dump []byte) []byte {
+	lines := strings.Split(string(dump), "\r\n")
+	for i, line := range lines {
+		for _, h := range redactedHeaders {
+			if strings.HasPrefix(line, h) {
+				lines[i] = h + "REDACTED"
+			}
+		}
 	}
+	return []byte(strings.Join(lines, "\r\n"))
 }
 
+// Sentinel errors that APIError.Is matches against, so callers can
+// write errors.Is(err, cardinity.ErrRateLimited) instead of parsing
+// APIError.Type/Status themselves.
+var (
+	ErrValidation      = errors.New("cardinity: validation error")
+	ErrAuthentication  = errors.New("cardinity: authentication error")
+	ErrRateLimited     = errors.New("cardinity: rate limited")
+	ErrCardDeclined    = errors.New("cardinity: card declined")
+	ErrThreeDSRequired = errors.New("cardinity: 3-D Secure authentication required")
+	ErrServer          = errors.New("cardinity: server error")
+)
+
 // APIError contains an API error response.
 type APIError struct {
 	Type   string `json:"type"`
@@ -50,6 +178,10 @@ type APIError struct {
 		Rejected string `json:"rejected"`
 		Message  string `json:"message"`
 	} `json:"errors"`
+
+	// RequestID is copied from the X-Request-Id response header, if
+	// present, so it can be quoted in Cardinity support tickets.
+	RequestID string `json:"-"`
 }
 
 // Error implements the error interface.
@@ -68,30 +200,295 @@ func (err *APIError) Error() string {
 	return strings.ToLower(b.String())
 }
 
-func oAuthString(key, secret, method, uri string) string {
-	ts := time.Now().UTC().Unix()
-	nonce := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%d", ts))))[:32]
+// Is implements errors.Is matching, mapping this error's Type/Status
+// onto the package's sentinel errors.
+func (err *APIError) Is(target error) bool {
+	t := strings.ToLower(err.Type)
+
+	switch target {
+	case ErrValidation:
+		return err.Status == http.StatusBadRequest
+	case ErrAuthentication:
+		return err.Status == http.StatusUnauthorized || err.Status == http.StatusForbidden
+	case ErrRateLimited:
+		return err.Status == http.StatusTooManyRequests
+	case ErrCardDeclined:
+		return strings.Contains(t, "declined")
+	case ErrThreeDSRequired:
+		return strings.Contains(t, "3ds") || strings.Contains(t, "three-d-secure") || strings.Contains(t, "authentication-required")
+	case ErrServer:
+		return err.Status >= 500
+	}
+
+	return false
+}
+
+// FieldErrors returns validation failures keyed by field name, so
+// callers can surface per-field messages without parsing Error()'s
+// string form. It returns nil if the API didn't report field-level
+// errors.
+func (err *APIError) FieldErrors() map[string]string {
+	if len(err.Errors) == 0 {
+		return nil
+	}
+
+	fe := make(map[string]string, len(err.Errors))
+	for _, e := range err.Errors {
+		fe[e.Field] = e.Message
+	}
+	return fe
+}
+
+// signer returns the effective OAuth1 signer. If the caller hasn't set
+// one explicitly, a HMAC-SHA1 signer is built on the fly from the
+// current ConsumerKey/ConsumerSecret, so mutating those fields after
+// construction keeps working as it always has.
+func (c *Cardinity) signer() oauth1.Signer {
+	if c.Signer != nil {
+		return c.Signer
+	}
+	return oauth1.NewHMACSHA1Signer(c.ConsumerKey, c.ConsumerSecret)
+}
+
+// Retryer decides whether a request should be retried after a failed
+// attempt, and how long to wait before doing so.
+type Retryer interface {
+	// ShouldRetry inspects the outcome of an attempt (1-indexed) and
+	// reports whether another attempt should be made, and if so, how
+	// long to wait before making it.
+	ShouldRetry(attempt int, resp *http.Response, err error) (bool, time.Duration)
+
+	// MaxAttempts returns the maximum number of attempts (including the
+	// first one) the retryer allows for a single request.
+	MaxAttempts() int
+}
+
+// StandardRetryer is the default Retryer. It retries on 429, on 5xx
+// responses other than 501 Not Implemented, and on transport-level
+// errors, using capped exponential backoff with jitter. A Retry-After
+// response header, when present, takes precedence over the computed
+// backoff.
+type StandardRetryer struct {
+	// Attempts is the maximum number of attempts, including the first
+	// one.
+	Attempts int
+
+	// BaseDelay is the delay used for the first retry.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the computed backoff, before jitter is applied.
+	MaxDelay time.Duration
+}
+
+// NewStandardRetryer returns a StandardRetryer configured with sane
+// defaults: three attempts, a 250ms base delay and a 5s cap.
+func NewStandardRetryer() *StandardRetryer {
+	return &StandardRetryer{
+		Attempts:  3,
+		BaseDelay: 250 * time.Millisecond,
+		MaxDelay:  5 * time.Second,
+	}
+}
+
+// MaxAttempts implements Retryer.
+func (r *StandardRetryer) MaxAttempts() int {
+	if r.Attempts <= 0 {
+		return 1
+	}
+	return r.Attempts
+}
+
+// ShouldRetry implements Retryer.
+func (r *StandardRetryer) ShouldRetry(attempt int, resp *http.Response, err error) (bool, time.Duration) {
+	if !r.isRetryable(resp, err) {
+		return false, 0
+	}
+
+	if resp != nil {
+		if d, ok := retryAfter(resp); ok {
+			return true, d
+		}
+	}
+
+	return true, r.backoff(attempt)
+}
+
+func (r *StandardRetryer) isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// A cancelled or expired caller context surfaces as a
+		// *url.Error that also satisfies net.Error (it delegates
+		// Timeout/Temporary), but it must fail fast rather than be
+		// retried: the caller already decided this request is done.
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+
+		// A net.Error (timeouts, connection resets, ...) is retryable.
+		_, ok := err.(net.Error)
+		return ok
+	}
+
+	if resp == nil {
+		return false
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	return resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented
+}
+
+func (r *StandardRetryer) backoff(attempt int) time.Duration {
+	base := r.BaseDelay
+	if base <= 0 {
+		base = 250 * time.Millisecond
+	}
+	max := r.MaxDelay
+	if max <= 0 {
+		max = 5 * time.Second
+	}
+
+	d := base * time.Duration(1<<uint(attempt-1))
+	if d > max || d <= 0 {
+		d = max
+	}
+
+	jitter := 0.5 + rand.Float64()*0.5
+	return time.Duration(float64(d) * jitter)
+}
+
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// requestOptions holds the per-request behavior that do() reads before
+// issuing the HTTP call.
+type requestOptions struct {
+	idempotent bool
+}
+
+// RequestOption customizes how a single request is issued and retried.
+type RequestOption func(*requestOptions)
 
-	p := url.Values{}
-	p.Add("oauth_consumer_key", key)
-	p.Add("oauth_signature_method", "HMAC-SHA1")
-	p.Add("oauth_timestamp", fmt.Sprintf("%d", ts))
-	p.Add("oauth_nonce", nonce)
-	p.Add("oauth_version", "1.0")
-	s := fmt.Sprintf("%s&%s&%s", strings.ToUpper(method), url.QueryEscape(uri), p.Encode())
-	p.Add("oauth_signature", Sign(secret, s, ""))
+// Idempotent marks a request as safe to retry and to tag with an
+// auto-generated Idempotency-Key header. Mutating Cardinity endpoints
+// (payments, refunds, ...) are not guaranteed to be idempotent unless
+// the caller opts in explicitly.
+func Idempotent(idempotent bool) RequestOption {
+	return func(o *requestOptions) {
+		o.idempotent = idempotent
+	}
+}
 
-	return p.Encode()
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := cryptorand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
 }
 
-func (c *Cardinity) do(req *http.Request, v interface{}) ([]byte, error) {
+func (c *Cardinity) do(ctx context.Context, req *http.Request, v interface{}, opts ...RequestOption) ([]byte, error) {
+	req = req.WithContext(ctx)
+
+	resolved, err := c.resolveURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = resolved
+	req.Host = resolved.Host
+
+	o := requestOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// Set request headers.
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("OAuth", oAuthString(c.ConsumerKey, c.ConsumerSecret, req.Method, req.URL.String()))
+	authHeader, err := c.signer().Sign(req.Method, req.URL.String(), req.URL.Query())
+	if err != nil {
+		return nil, errors.Wrap(err, "signing request")
+	}
+	req.Header.Set("Authorization", authHeader)
+	if o.idempotent {
+		req.Header.Set("Idempotency-Key", newIdempotencyKey())
+	}
+
+	retryer := c.Retryer
+	if retryer == nil {
+		retryer = NewStandardRetryer()
+	}
+
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading request body")
+		}
+		req.Body.Close()
+		body = b
+	}
+
+	client := c.httpClient()
+	var res *http.Response
+
+	// GET/HEAD are safe by definition and are always retry-eligible;
+	// other methods (POST, ...) may not be idempotent server-side and
+	// only become retry-eligible when the caller opts in explicitly.
+	safe := req.Method == http.MethodGet || req.Method == http.MethodHead
+	retryable := safe || o.idempotent
+
+	start := time.Now()
+	for attempt := 1; attempt <= retryer.MaxAttempts(); attempt++ {
+		if body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+
+		if c.Debug {
+			if dump, derr := httputil.DumpRequestOut(req, true); derr == nil {
+				c.log.Debugf("request attempt %d:\n%s", attempt, redactDump(dump))
+			}
+		}
+
+		res, err = client.Do(req)
+
+		if !retryable || attempt == retryer.MaxAttempts() {
+			break
+		}
+
+		retry, delay := retryer.ShouldRetry(attempt, res, err)
+		if !retry {
+			break
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+		time.Sleep(delay)
+	}
+	elapsed := time.Since(start)
+
+	if c.Debug {
+		if err != nil {
+			c.log.Errorf("%s %s failed after %s: %v", req.Method, req.URL, elapsed, err)
+		} else if dump, derr := httputil.DumpResponse(res, true); derr == nil {
+			c.log.Debugf("response for %s %s in %s:\n%s", req.Method, req.URL, elapsed, redactDump(dump))
+		}
+	}
 
-	// Make API request.
-	client := http.Client{}
-	res, err := client.Do(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "making request")
 	}
@@ -106,6 +503,8 @@ func (c *Cardinity) do(req *http.Request, v interface{}) ([]byte, error) {
 			return nil, fmt.Errorf("unexpected error: %s", res.Status)
 		}
 
+		e.RequestID = res.Header.Get("X-Request-Id")
+
 		// Return APIError
 		return nil, &e
 	}
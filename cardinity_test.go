@@ -0,0 +1,98 @@
+package cardinity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDo_UsesBaseURLAndHTTPClient(t *testing.T) {
+	var gotPath, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer srv.Close()
+
+	c := New("key", "secret")
+	c.BaseURL = srv.URL
+	c.HTTPClient = srv.Client()
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"payments/abc", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	var v struct {
+		Status string `json:"status"`
+	}
+	if _, err := c.do(context.Background(), req, &v); err != nil {
+		t.Fatalf("do() error = %v", err)
+	}
+
+	if v.Status != "ok" {
+		t.Errorf("Status = %q, want %q", v.Status, "ok")
+	}
+	if gotPath != "/v1/payments/abc" {
+		t.Errorf("server saw path %q, want %q", gotPath, "/v1/payments/abc")
+	}
+	if gotAuth == "" {
+		t.Error("server saw no Authorization header")
+	}
+}
+
+func TestResolveURL_DefaultBaseURLIsNoop(t *testing.T) {
+	c := New("key", "secret")
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"payments/abc", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	resolved, err := c.resolveURL(req.URL)
+	if err != nil {
+		t.Fatalf("resolveURL() error = %v", err)
+	}
+
+	if got, want := resolved.String(), baseURL+"payments/abc"; got != want {
+		t.Errorf("resolveURL() = %q, want %q (BaseURL is unset, so this must be a no-op)", got, want)
+	}
+}
+
+func TestDo_RetriesSafeMethodWithoutIdempotentOption(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer srv.Close()
+
+	c := New("key", "secret")
+	c.BaseURL = srv.URL
+	c.HTTPClient = srv.Client()
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"payments/abc", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	var v struct {
+		Status string `json:"status"`
+	}
+	if _, err := c.do(context.Background(), req, &v); err != nil {
+		t.Fatalf("do() error = %v", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
@@ -0,0 +1,104 @@
+package cardinity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type capturingLogger struct {
+	debugLines []string
+	errorLines []string
+}
+
+func (l *capturingLogger) Debugf(format string, args ...interface{}) {
+	l.debugLines = append(l.debugLines, fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) Errorf(format string, args ...interface{}) {
+	l.errorLines = append(l.errorLines, fmt.Sprintf(format, args...))
+}
+
+func TestDo_DebugRedactsAuthorizationHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer srv.Close()
+
+	c := New("key", "secret")
+	c.BaseURL = srv.URL
+	c.HTTPClient = srv.Client()
+	c.Debug = true
+	logger := &capturingLogger{}
+	c.log = logger
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"payments/abc", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	var v struct {
+		Status string `json:"status"`
+	}
+	if _, err := c.do(context.Background(), req, &v); err != nil {
+		t.Fatalf("do() error = %v", err)
+	}
+
+	if len(logger.debugLines) == 0 {
+		t.Fatal("no debug lines captured, want request/response dumps")
+	}
+
+	authHeader := req.Header.Get("Authorization")
+	if authHeader == "" {
+		t.Fatal("request has no Authorization header to check redaction against")
+	}
+
+	for _, line := range logger.debugLines {
+		if strings.Contains(line, authHeader) {
+			t.Errorf("debug line leaked the raw Authorization header value:\n%s", line)
+		}
+		if strings.Contains(line, "Authorization: "+authHeader) {
+			t.Errorf("debug line leaked the raw Authorization header:\n%s", line)
+		}
+	}
+
+	joined := strings.Join(logger.debugLines, "\n")
+	if !strings.Contains(joined, "Authorization: REDACTED") {
+		t.Errorf("expected a redacted Authorization line in debug output, got:\n%s", joined)
+	}
+}
+
+func TestDo_NoDebugOutputWhenDisabled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer srv.Close()
+
+	c := New("key", "secret")
+	c.BaseURL = srv.URL
+	c.HTTPClient = srv.Client()
+	logger := &capturingLogger{}
+	c.log = logger
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"payments/abc", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	var v struct {
+		Status string `json:"status"`
+	}
+	if _, err := c.do(context.Background(), req, &v); err != nil {
+		t.Fatalf("do() error = %v", err)
+	}
+
+	if len(logger.debugLines) != 0 || len(logger.errorLines) != 0 {
+		t.Errorf("expected no log output with Debug=false, got debug=%v error=%v", logger.debugLines, logger.errorLines)
+	}
+}
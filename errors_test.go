@@ -0,0 +1,125 @@
+package cardinity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIError_Is(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *APIError
+		target error
+		want   bool
+	}{
+		{"validation by status", &APIError{Status: http.StatusBadRequest}, ErrValidation, true},
+		{"validation wrong status", &APIError{Status: http.StatusOK}, ErrValidation, false},
+		{"authentication 401", &APIError{Status: http.StatusUnauthorized}, ErrAuthentication, true},
+		{"authentication 403", &APIError{Status: http.StatusForbidden}, ErrAuthentication, true},
+		{"rate limited", &APIError{Status: http.StatusTooManyRequests}, ErrRateLimited, true},
+		{"card declined", &APIError{Type: "declined"}, ErrCardDeclined, true},
+		{"card declined, different case", &APIError{Type: "Payment-Declined"}, ErrCardDeclined, true},
+		{"three-d-secure required", &APIError{Type: "three-d-secure-authentication-required"}, ErrThreeDSRequired, true},
+		{"server error", &APIError{Status: http.StatusBadGateway}, ErrServer, true},
+		{"server error excludes 4xx", &APIError{Status: http.StatusBadRequest}, ErrServer, false},
+		{"unrelated sentinel", &APIError{Status: http.StatusBadRequest}, ErrRateLimited, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is(err, target) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAPIError_Is_RateLimitedAndValidationAreIndependent documents that
+// Is() checks each sentinel independently: a 429 response that also
+// carries field errors matches both ErrRateLimited and FieldErrors(),
+// but NOT ErrValidation, since that sentinel is tied to the 400 status
+// only. This is deliberate, not an oversight.
+func TestAPIError_Is_RateLimitedAndValidationAreIndependent(t *testing.T) {
+	err := &APIError{
+		Status: http.StatusTooManyRequests,
+		Errors: []struct {
+			Field    string `json:"field"`
+			Rejected string `json:"rejected"`
+			Message  string `json:"message"`
+		}{{Field: "amount", Message: "too many requests for this amount"}},
+	}
+
+	if !errors.Is(err, ErrRateLimited) {
+		t.Error("errors.Is(err, ErrRateLimited) = false, want true")
+	}
+	if errors.Is(err, ErrValidation) {
+		t.Error("errors.Is(err, ErrValidation) = true, want false (status is 429, not 400)")
+	}
+	if fe := err.FieldErrors(); fe["amount"] == "" {
+		t.Errorf("FieldErrors()[\"amount\"] is empty, want the field message")
+	}
+}
+
+func TestAPIError_FieldErrors(t *testing.T) {
+	err := &APIError{
+		Errors: []struct {
+			Field    string `json:"field"`
+			Rejected string `json:"rejected"`
+			Message  string `json:"message"`
+		}{
+			{Field: "cvc", Rejected: "12", Message: "must be 3 or 4 digits"},
+			{Field: "holder", Rejected: "", Message: "is required"},
+		},
+	}
+
+	fe := err.FieldErrors()
+	if fe["cvc"] != "must be 3 or 4 digits" {
+		t.Errorf("FieldErrors()[\"cvc\"] = %q, want %q", fe["cvc"], "must be 3 or 4 digits")
+	}
+	if fe["holder"] != "is required" {
+		t.Errorf("FieldErrors()[\"holder\"] = %q, want %q", fe["holder"], "is required")
+	}
+
+	if got := (&APIError{}).FieldErrors(); got != nil {
+		t.Errorf("FieldErrors() on an error with no field errors = %v, want nil", got)
+	}
+}
+
+func TestDo_PopulatesRequestIDFromHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-123")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIError{Type: "validation_error", Title: "Bad Request", Status: http.StatusBadRequest})
+	}))
+	defer srv.Close()
+
+	c := New("key", "secret")
+	c.BaseURL = srv.URL
+	c.HTTPClient = srv.Client()
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"payments/abc", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	_, err = c.do(context.Background(), req, nil)
+	if err == nil {
+		t.Fatal("do() error = nil, want an *APIError")
+	}
+
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("do() error type = %T, want *APIError", err)
+	}
+	if apiErr.RequestID != "req-123" {
+		t.Errorf("RequestID = %q, want %q", apiErr.RequestID, "req-123")
+	}
+	if !errors.Is(apiErr, ErrValidation) {
+		t.Error("errors.Is(apiErr, ErrValidation) = false, want true")
+	}
+}
@@ -0,0 +1,123 @@
+package cardinity
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// fakeNetError is a plain net.Error that, unlike *url.Error wrapping a
+// context error, does not unwrap to context.Canceled/DeadlineExceeded.
+type fakeNetError struct{ timeout bool }
+
+func (e *fakeNetError) Error() string   { return "fake net error" }
+func (e *fakeNetError) Timeout() bool   { return e.timeout }
+func (e *fakeNetError) Temporary() bool { return e.timeout }
+
+func contextErr(err error) error {
+	return &url.Error{Op: "Get", URL: "https://api.cardinity.com/v1/payments", Err: err}
+}
+
+func TestStandardRetryer_IsRetryable(t *testing.T) {
+	r := NewStandardRetryer()
+
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{"501 not retried", &http.Response{StatusCode: http.StatusNotImplemented}, nil, false},
+		{"503 retried", &http.Response{StatusCode: http.StatusServiceUnavailable}, nil, true},
+		{"429 retried", &http.Response{StatusCode: http.StatusTooManyRequests}, nil, true},
+		{"200 not retried", &http.Response{StatusCode: http.StatusOK}, nil, false},
+		{"400 not retried", &http.Response{StatusCode: http.StatusBadRequest}, nil, false},
+		{"context.Canceled not retried", nil, contextErr(context.Canceled), false},
+		{"context.DeadlineExceeded not retried", nil, contextErr(context.DeadlineExceeded), false},
+		{"net.Error timeout retried", nil, &fakeNetError{timeout: true}, true},
+		{"plain error not retried", nil, errPlain("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.isRetryable(tt.resp, tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v, %v) = %v, want %v", tt.resp, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+type errPlain string
+
+func (e errPlain) Error() string { return string(e) }
+
+func TestStandardRetryer_ShouldRetry_RetryAfterSeconds(t *testing.T) {
+	r := NewStandardRetryer()
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "5")
+
+	retry, delay := r.ShouldRetry(1, resp, nil)
+	if !retry {
+		t.Fatal("ShouldRetry() = false, want true")
+	}
+	if delay != 5*time.Second {
+		t.Errorf("delay = %v, want 5s", delay)
+	}
+}
+
+func TestStandardRetryer_ShouldRetry_RetryAfterHTTPDate(t *testing.T) {
+	r := NewStandardRetryer()
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+	resp.Header.Set("Retry-After", time.Now().Add(10*time.Second).UTC().Format(http.TimeFormat))
+
+	retry, delay := r.ShouldRetry(1, resp, nil)
+	if !retry {
+		t.Fatal("ShouldRetry() = false, want true")
+	}
+	if delay <= 8*time.Second || delay > 10*time.Second {
+		t.Errorf("delay = %v, want ~10s", delay)
+	}
+}
+
+func TestStandardRetryer_ShouldRetry_PastRetryAfterFallsBackToBackoff(t *testing.T) {
+	r := NewStandardRetryer()
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+	resp.Header.Set("Retry-After", time.Now().Add(-10*time.Second).UTC().Format(http.TimeFormat))
+
+	retry, delay := r.ShouldRetry(1, resp, nil)
+	if !retry {
+		t.Fatal("ShouldRetry() = false, want true")
+	}
+	if delay <= 0 || delay > r.MaxDelay {
+		t.Errorf("delay = %v, want a computed backoff in (0, %v]", delay, r.MaxDelay)
+	}
+}
+
+func TestStandardRetryer_Backoff_CappedAtMaxDelay(t *testing.T) {
+	r := &StandardRetryer{BaseDelay: 100 * time.Millisecond, MaxDelay: 100 * time.Millisecond}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := r.backoff(attempt)
+		if d <= 0 || d > r.MaxDelay {
+			t.Errorf("backoff(%d) = %v, want in (0, %v]", attempt, d, r.MaxDelay)
+		}
+	}
+}
+
+func TestStandardRetryer_Backoff_GrowsWithAttempt(t *testing.T) {
+	r := &StandardRetryer{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Hour}
+
+	const samples = 200
+	var sum1, sum4 time.Duration
+	for i := 0; i < samples; i++ {
+		sum1 += r.backoff(1)
+		sum4 += r.backoff(4)
+	}
+	avg1, avg4 := sum1/samples, sum4/samples
+
+	if avg4 <= avg1 {
+		t.Errorf("average backoff(4) = %v, want > average backoff(1) = %v", avg4, avg1)
+	}
+}